@@ -2,11 +2,12 @@ use crate::ts_config::{get_aliases, get_base_path, get_closest};
 
 use super::languages::ParsedFile;
 use super::languages::TestFile;
-use super::package_json::{list_dependencies, PackageJson};
+use super::languages::EXTENSIONS;
+use super::package_json::{entry_points, list_dependencies, PackageJson};
 use super::ts_config::TypeScriptConfig;
 use serde::Serialize;
 use std::cmp::Ordering;
-use std::collections::HashMap;
+use std::collections::{HashMap, HashSet};
 use std::path::{Component, Path, PathBuf};
 
 #[derive(Serialize)]
@@ -15,22 +16,32 @@ pub struct Summary {
     pub import_count: usize,
     pub file_count: usize,
     pub unused_file_count: usize,
+    pub failed_file_count: usize,
 }
 impl std::fmt::Display for Summary {
     fn fmt(&self, f: &mut std::fmt::Formatter) -> std::fmt::Result {
         write!(
             f,
-            "Total Files:     {}\nTotal Lines:     {}\nTotal Imports:   {}\nDead Files:      {}",
-            self.file_count, self.line_count, self.import_count, self.unused_file_count
+            "Total Files:     {}\nTotal Lines:     {}\nTotal Imports:   {}\nDead Files:      {}\nFailed Files:    {}",
+            self.file_count,
+            self.line_count,
+            self.import_count,
+            self.unused_file_count,
+            self.failed_file_count
         )
     }
 }
 
+/// Version of the report.json shape. Bump on any breaking change to `Output`.
+pub const SCHEMA_VERSION: &str = "1";
+
 #[derive(Serialize)]
 pub struct Output {
+    pub schema_version: String,
     pub import_graph: ImportGraph,
     pub dead_files: Vec<String>,
     pub unknown_imports: Vec<String>,
+    pub failed_files: Vec<String>,
     pub exports: Vec<FileExports>,
     pub summary: Summary,
     pub package_json: PackageJsonExtract,
@@ -142,11 +153,14 @@ pub fn extract_dead_files(
 pub fn extract_import_graph(
     files: &Vec<ParsedFile>,
     ts_configs: &Vec<TypeScriptConfig>,
+    package_jsons: &Vec<PackageJson>,
 ) -> ImportGraph {
     let mut node_count = 0;
     let mut edge_count = 0;
     let mut node_map: HashMap<String, Node> = HashMap::new();
     let mut edges: Vec<Edge> = Vec::new();
+    let file_paths: HashSet<String> = files.iter().map(|f| f.path.clone()).collect();
+    let package_entries = package_entries(package_jsons);
     for file in files {
         let ts_config = get_closest(ts_configs, PathBuf::from(&file.path));
         let aliases = get_aliases(ts_config.cloned());
@@ -165,7 +179,8 @@ pub fn extract_import_graph(
             None => String::from(""),
         };
         // Way may have mapped an "index" file in which case only the directory name exists in the node_map
-        if node_map.contains_key(&dir) && file_name == "index" {
+        if node_map.contains_key(&dir) && file_name == "index" && !node_map.contains_key(file_path)
+        {
             // Mapping to the parent
             let old = node_map.get(&dir).unwrap();
             let id = old.id;
@@ -213,8 +228,9 @@ pub fn extract_import_graph(
         for import in &file.imports {
             // Normalize import path to a real path or npm module
             let mut src = import.source.clone();
+            let mut is_local = src.starts_with(".");
             // Could be: NPM module, alias or genuinely a relative import.
-            if src.starts_with(".") {
+            if is_local {
                 // Genuine relative path
                 let mut file_path = PathBuf::from(&import.file_path);
                 // Get to the directory
@@ -249,6 +265,7 @@ pub fn extract_import_graph(
                                 path = path.join(PathBuf::from(&replaced));
                                 // Normalize the final path
                                 src = normalize_path(&PathBuf::from(path)).display().to_string();
+                                is_local = true;
                             }
                         }
                     }
@@ -258,6 +275,15 @@ pub fn extract_import_graph(
             if src.ends_with('/') {
                 src.pop();
             }
+            // Import specifiers usually leave out the extension or index file. Point them at the
+            // scanned file so the importer and the imported file share a node.
+            if is_local {
+                if let Some(resolved) =
+                    resolve_import(Path::new(&src), &file_paths, &package_entries)
+                {
+                    src = resolved;
+                }
+            }
             if !node_map.contains_key(&src) {
                 node_map.insert(
                     src.to_string(),
@@ -295,10 +321,111 @@ pub fn extract_import_graph(
             }
         }
     }
-    let nodes = node_map.values().cloned().collect::<Vec<Node>>();
+    let mut nodes = node_map.values().cloned().collect::<Vec<Node>>();
+    nodes.sort();
     return ImportGraph { nodes, edges };
 }
 
+/// Paths an import of `path` could refer to: as written, with a supported extension or as a
+/// directory index file.
+fn import_candidates(path: &Path) -> Vec<PathBuf> {
+    let mut candidates = vec![path.to_path_buf()];
+    // TypeScript ESM imports name the emitted file, e.g. './util.js' for util.ts
+    let ts_extensions: &[&str] = match path.extension().and_then(|e| e.to_str()) {
+        Some("js") => &["ts", "tsx"],
+        Some("jsx") => &["tsx"],
+        Some("mjs") => &["mts"],
+        Some("cjs") => &["cts"],
+        _ => &[],
+    };
+    for ext in ts_extensions {
+        candidates.push(path.with_extension(ext));
+    }
+    // A file with the imported name wins over a directory index, whatever the extensions
+    for ext in EXTENSIONS {
+        let mut with_ext = path.as_os_str().to_os_string();
+        with_ext.push(format!(".{}", ext));
+        candidates.push(PathBuf::from(with_ext));
+    }
+    for ext in EXTENSIONS {
+        candidates.push(path.join(format!("index.{}", ext)));
+    }
+    return candidates;
+}
+
+/// Entry files declared by each package.json, keyed by the directory it sits in.
+fn package_entries(package_jsons: &Vec<PackageJson>) -> HashMap<PathBuf, Vec<PathBuf>> {
+    let mut entries: HashMap<PathBuf, Vec<PathBuf>> = HashMap::new();
+    for p_json in package_jsons {
+        let mut dir = match &p_json.file_path {
+            Some(file_path) => file_path.clone(),
+            None => continue,
+        };
+        dir.pop(); // Last component is the file name
+        let dir_entries = entry_points(p_json)
+            .iter()
+            .map(|entry| normalize_path(&dir.join(entry)))
+            .collect();
+        entries.insert(dir, dir_entries);
+    }
+    return entries;
+}
+
+/// Paths an import of `path` could refer to, including the entry files of a directory's
+/// package.json when `path` is a package directory.
+fn resolution_candidates(
+    path: &Path,
+    package_entries: &HashMap<PathBuf, Vec<PathBuf>>,
+) -> Vec<PathBuf> {
+    let mut candidates = import_candidates(path);
+    if let Some(entries) = package_entries.get(path) {
+        for entry in entries {
+            candidates.append(&mut import_candidates(entry));
+        }
+    }
+    return candidates;
+}
+
+/// Scanned file an import of `path` refers to, if any.
+fn resolve_import(
+    path: &Path,
+    file_paths: &HashSet<String>,
+    package_entries: &HashMap<PathBuf, Vec<PathBuf>>,
+) -> Option<String> {
+    return resolution_candidates(path, package_entries)
+        .iter()
+        .map(|c| c.display().to_string())
+        .find(|c| file_paths.contains(c));
+}
+
+/// Relative imports that don't point at a file on disk, either directly or through the entry of a
+/// directory's package.json. Bare package and alias imports are not reported.
+pub fn extract_unresolved_imports(
+    root: &Path,
+    files: &Vec<ParsedFile>,
+    package_jsons: &Vec<PackageJson>,
+) -> Vec<String> {
+    let package_entries = package_entries(package_jsons);
+    let mut unresolved: Vec<String> = Vec::new();
+    for file in files {
+        for import in &file.imports {
+            if !import.source.starts_with(".") {
+                continue;
+            }
+            let mut dir = PathBuf::from(&import.file_path);
+            dir.pop();
+            let target = normalize_path(&dir.join(&import.source));
+            let candidates = resolution_candidates(&target, &package_entries);
+            if !candidates.iter().any(|c| root.join(c).is_file()) {
+                unresolved.push(format!("{}: {}", import.file_path, import.source));
+            }
+        }
+    }
+    unresolved.sort();
+    unresolved.dedup();
+    return unresolved;
+}
+
 #[derive(Serialize)]
 pub struct Export {
     name: String,
@@ -406,13 +533,14 @@ pub fn extract_package_json(
 pub fn extract(
     root: &Path,
     files: Vec<ParsedFile>,
+    failed_files: Vec<String>,
     package_jsons: Vec<PackageJson>,
     ts_configs: Vec<TypeScriptConfig>,
 ) -> Output {
     let file_count = files.len();
     let mut line_count = 0;
     let mut import_count: usize = 0;
-    let import_graph = extract_import_graph(&files, &ts_configs);
+    let import_graph = extract_import_graph(&files, &ts_configs, &package_jsons);
     let package_json = extract_package_json(&files, package_jsons);
     let dependencies = package_json.clone().dependencies.into_keys().collect();
     let (dead_files, unknown_imports) = extract_dead_files(&import_graph, dependencies, root);
@@ -426,11 +554,14 @@ pub fn extract(
         import_count,
         file_count,
         unused_file_count: dead_files.len(),
+        failed_file_count: failed_files.len(),
     };
     return Output {
+        schema_version: SCHEMA_VERSION.to_string(),
         import_graph,
         dead_files,
         unknown_imports,
+        failed_files,
         exports,
         summary,
         package_json,
@@ -473,3 +604,98 @@ pub fn extract_test_files(test_files: Vec<TestFile>) -> (TestSummary, TestOutput
         TestOutput {},
     );
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::languages::{mock_file, mock_import};
+    use crate::package_json;
+    fn mock_package_jsons() -> Vec<PackageJson> {
+        package_json::parse(
+            vec![PathBuf::from("tests/mocks/pkg/package.json")],
+            PathBuf::from("tests/mocks"),
+        )
+    }
+    #[test]
+    fn test_schema_version() -> Result<(), String> {
+        let output = extract(Path::new("tests/mocks"), vec![], vec![], vec![], vec![]);
+        let json = serde_json::to_value(&output).unwrap();
+        assert_eq!(json["schema_version"], SCHEMA_VERSION);
+        assert_eq!(SCHEMA_VERSION, "1");
+        Ok(())
+    }
+    #[test]
+    fn test_local_import_resolution() -> Result<(), String> {
+        // './import' is import.js on disk, it must not be reported as dead or unknown
+        let files = vec![
+            mock_file("export.js", vec![mock_import("export.js", "./import")]),
+            mock_file("import.js", vec![]),
+        ];
+        let graph = extract_import_graph(&files, &vec![], &vec![]);
+        assert_eq!(graph.nodes.len(), 2);
+        let (dead_files, unknown_imports) =
+            extract_dead_files(&graph, vec![], Path::new("tests/mocks"));
+        assert!(dead_files.is_empty());
+        assert!(unknown_imports.is_empty());
+        Ok(())
+    }
+    #[test]
+    fn test_unresolved_imports() -> Result<(), String> {
+        let import = |source: &str| mock_import("export.js", source);
+        let files = vec![mock_file(
+            "export.js",
+            vec![
+                import("./import"),
+                import("./missing"),
+                import("./missing"),
+                import("./pkg"),
+                import("./util.js"),
+                import("./util.mjs"),
+                import("module-name"),
+            ],
+        )];
+        assert_eq!(
+            extract_unresolved_imports(Path::new("tests/mocks"), &files, &mock_package_jsons()),
+            vec![
+                String::from("export.js: ./missing"),
+                String::from("export.js: ./util.mjs")
+            ]
+        );
+        Ok(())
+    }
+    #[test]
+    fn test_package_entry_resolution() -> Result<(), String> {
+        let files = vec![
+            mock_file("App.js", vec![mock_import("App.js", "./pkg")]),
+            mock_file("pkg/lib/entry.js", vec![]),
+        ];
+        let output = extract(
+            Path::new("tests/mocks"),
+            files,
+            vec![],
+            mock_package_jsons(),
+            vec![],
+        );
+        assert!(output.dead_files.is_empty());
+        assert!(output.unknown_imports.is_empty());
+        assert_eq!(output.import_graph.nodes.len(), 2);
+        Ok(())
+    }
+    #[test]
+    fn test_file_preferred_over_directory_index() -> Result<(), String> {
+        let files = vec![
+            mock_file("src/App.js", vec![mock_import("src/App.js", "./Button")]),
+            mock_file("src/Button.js", vec![]),
+            mock_file("src/Button/index.jsx", vec![]),
+        ];
+        let graph = extract_import_graph(&files, &vec![], &vec![]);
+        assert_eq!(graph.edges.len(), 1);
+        let imported = graph
+            .nodes
+            .iter()
+            .find(|n| n.id == graph.edges[0].source)
+            .unwrap();
+        assert_eq!(imported.path, "src/Button.js");
+        Ok(())
+    }
+}
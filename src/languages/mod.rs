@@ -49,6 +49,32 @@ pub trait Language {
     fn parse_test_file(&self, path: &Path) -> Result<TestFile, Error>;
 }
 
+/// File extensions that have a supported language parser.
+pub const EXTENSIONS: [&str; 8] = ["jsx", "js", "mjs", "cjs", "tsx", "ts", "mts", "cts"];
+
+/// Narrow the supported extensions down to the ones requested. Extensions may be given with or
+/// without a leading '.'. An empty list means everything supported.
+pub fn include_extensions(include: &Vec<String>) -> Result<Vec<String>, String> {
+    if include.is_empty() {
+        return Ok(EXTENSIONS.iter().map(|e| e.to_string()).collect());
+    }
+    let mut extensions: Vec<String> = Vec::new();
+    for ext in include {
+        let ext = ext.trim().trim_start_matches('.').to_string();
+        if !EXTENSIONS.contains(&ext.as_str()) {
+            return Err(format!(
+                "Unsupported extension: .{} (supported: .{})",
+                ext,
+                EXTENSIONS.join(", .")
+            ));
+        }
+        if !extensions.contains(&ext) {
+            extensions.push(ext);
+        }
+    }
+    return Ok(extensions);
+}
+
 const JS: JavaScript = JavaScript {};
 const TS: TypeScript = TypeScript {};
 const UK: Unknown = Unknown {};
@@ -60,7 +86,11 @@ pub fn parse_file(path: &Path, prefix: PathBuf) -> Result<ParsedFile, Error> {
         Some(os_str) => match os_str.to_str() {
             Some("js") => JS.parse_file(&path, prefix),
             Some("ts") => TS.parse_file(&path, prefix),
+            Some("mjs") => JS.parse_file(&path, prefix),
+            Some("cjs") => JS.parse_file(&path, prefix),
             Some("jsx") => JS.parse_file(&path, prefix),
+            Some("mts") => TS.parse_file(&path, prefix),
+            Some("cts") => TS.parse_file(&path, prefix),
             Some("tsx") => TS.parse_file(&path, prefix),
             _ => panic!("You forgot to specify this case!"),
         },
@@ -72,9 +102,68 @@ pub fn parse_test_file(path: &Path) -> Result<TestFile, Error> {
         Some(os_str) => match os_str.to_str() {
             Some("js") => JS.parse_test_file(&path),
             Some("ts") => TS.parse_test_file(&path),
+            Some("mjs") => JS.parse_test_file(&path),
+            Some("cjs") => JS.parse_test_file(&path),
             Some("jsx") => JS.parse_test_file(&path),
+            Some("mts") => TS.parse_test_file(&path),
+            Some("cts") => TS.parse_test_file(&path),
             Some("tsx") => TS.parse_test_file(&path),
             _ => panic!("You forgot to specify this case!"),
         },
     };
 }
+
+/// Parsed file fixture for tests, with name and extension taken from `path`.
+#[cfg(test)]
+pub fn mock_file(path: &str, imports: Vec<Import>) -> ParsedFile {
+    let path_buf = PathBuf::from(path);
+    let part = |p: Option<&std::ffi::OsStr>| p.and_then(|s| s.to_str()).unwrap_or("").to_string();
+    return ParsedFile {
+        line_count: 0,
+        imports,
+        exports: vec![],
+        name: part(path_buf.file_stem()),
+        extension: part(path_buf.extension()),
+        path: path.to_string(),
+    };
+}
+
+/// Default import fixture for tests, `import x from '<source>'` in the file at `file_path`.
+#[cfg(test)]
+pub fn mock_import(file_path: &str, source: &str) -> Import {
+    return Import {
+        source: source.to_string(),
+        file_path: file_path.to_string(),
+        named: vec![],
+        is_default: true,
+        line: 0,
+    };
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    #[test]
+    fn test_parse_module_extensions() -> Result<(), String> {
+        let parsed = parse_file(
+            &Path::new("tests/mocks/import.mts"),
+            PathBuf::from("tests/mocks"),
+        )
+        .unwrap();
+        assert_eq!(parsed.extension, String::from("mts"));
+        assert_eq!(parsed.path, String::from("import.mts"));
+        assert_eq!(parsed.imports.len(), 2);
+        assert_eq!(parsed.imports[0].source, String::from("./module-name"));
+        Ok(())
+    }
+    #[test]
+    fn test_include_extensions() -> Result<(), String> {
+        assert_eq!(include_extensions(&vec![])?.len(), EXTENSIONS.len());
+        assert_eq!(
+            include_extensions(&vec![String::from(".tsx"), String::from("jsx")])?,
+            vec![String::from("tsx"), String::from("jsx")]
+        );
+        assert!(include_extensions(&vec![String::from(".vue")]).is_err());
+        Ok(())
+    }
+}
@@ -17,20 +17,64 @@ mod ts_config;
 struct Cli {
     /// Path to folder root
     path: std::path::PathBuf,
+    /// Only scan files with these extensions, e.g. --include=.tsx,.jsx
+    #[arg(long, value_delimiter = ',')]
+    include: Vec<String>,
+    /// Skip directories with this name (repeatable). node_modules is always skipped.
+    #[arg(long = "skip-dir")]
+    skip_dirs: Vec<String>,
+    /// Print the file import graph in the given format and exit
+    #[arg(long, value_enum, conflicts_with = "warn_unresolved")]
+    import_graph: Option<output::GraphFormat>,
+    /// List relative imports that don't resolve to a file
+    #[arg(long)]
+    warn_unresolved: bool,
+    /// Print the files that would be analyzed and exit
+    #[arg(long, conflicts_with_all = ["import_graph", "warn_unresolved"])]
+    list_files: bool,
+    /// Print --list-files output as a JSON array
+    #[arg(long, requires = "list_files")]
+    json: bool,
     // language: String,
 }
 
 fn main() {
     let now = Instant::now();
     {
-        print::welcome_message();
         // Parse command line arguments
         let args = Cli::parse();
         let root = Path::new(&args.path);
+        let extensions = match languages::include_extensions(&args.include) {
+            Ok(extensions) => extensions.join("|"),
+            Err(message) => {
+                eprintln!("{}", message);
+                std::process::exit(2);
+            }
+        };
         // Default patterns. Need cli or config file to override.
-        let pattern = Regex::new(r"^.*\.(jsx|js|tsx|ts)$").unwrap();
+        let pattern = Regex::new(&format!(r"^.*\.({})$", extensions)).unwrap();
         let ignore_pattern: Regex = Regex::new(r".*.test.js").unwrap();
-        let test_pattern: Regex = Regex::new(r".*.(cy|test|spec|unit).(jsx|tsx|js|ts)$").unwrap();
+        let test_pattern: Regex =
+            Regex::new(&format!(r".*.(cy|test|spec|unit).({})$", extensions)).unwrap();
+        if args.list_files {
+            let files = scan::list_files(root, &pattern, &ignore_pattern, &args.skip_dirs);
+            match args.json {
+                true => println!("{}", serde_json::to_string(&files).unwrap()),
+                false => println!("{}", files.join("\n")),
+            }
+            return;
+        }
+        if let Some(format) = &args.import_graph {
+            let (files, failed_files, package_jsons, ts_configs) =
+                scan::scan(root, &pattern, &ignore_pattern, &args.skip_dirs);
+            let output = extract::extract(root, files, failed_files, package_jsons, ts_configs);
+            println!(
+                "{}",
+                output::render_import_graph(&output.import_graph, format)
+            );
+            return;
+        }
+        print::welcome_message();
         print::input(
             root,
             pattern.clone(),
@@ -38,13 +82,29 @@ fn main() {
             test_pattern.clone(),
         );
         // Scan Files
-        let (files, package_jsons, ts_configs) = scan::scan(root, &pattern, &ignore_pattern);
-        let output = extract::extract(root, files, package_jsons, ts_configs);
+        let scan_time = Instant::now();
+        let (files, failed_files, package_jsons, ts_configs) =
+            scan::scan(root, &pattern, &ignore_pattern, &args.skip_dirs);
+        println!("Scan done in: {:.2?}!", scan_time.elapsed());
+        let unresolved = match args.warn_unresolved {
+            true => extract::extract_unresolved_imports(root, &files, &package_jsons),
+            false => Vec::new(),
+        };
+        let output = extract::extract(root, files, failed_files, package_jsons, ts_configs);
         let _ = output::write_output(&output);
         println!("=== File Summary ===\n{}\n", output.summary);
+        if args.warn_unresolved {
+            println!(
+                "=== Unresolved Imports ===\n{}\n",
+                match unresolved.is_empty() {
+                    true => String::from("None"),
+                    false => unresolved.join("\n"),
+                }
+            );
+        }
         // Scan Test Files
         let test_files: Vec<languages::TestFile> =
-            scan::scan_test_files(root, &test_pattern, &ignore_pattern);
+            scan::scan_test_files(root, &test_pattern, &ignore_pattern, &args.skip_dirs);
         let (test_summary, _) = extract::extract_test_files(test_files);
         println!("=== Test Summary ===\n{}\n", test_summary);
     }
@@ -1,13 +1,21 @@
-use super::extract::Output;
+use super::extract::{ImportGraph, Output};
+use clap::ValueEnum;
 use handlebars::Handlebars;
 use serde_json;
 use serde_json::json;
+use std::collections::HashMap;
 use std::fs::create_dir_all;
 use std::fs::File;
 use std::io::BufWriter;
 use std::io::Write;
 use std::path::Path;
 
+#[derive(Clone, Debug, ValueEnum)]
+pub enum GraphFormat {
+    Mermaid,
+    Dot,
+}
+
 pub fn write_output(output: &Output) -> std::io::Result<()> {
     // Write main report
     let mut file = File::create("ui/src/report.json")?;
@@ -42,3 +50,101 @@ pub fn write_output(output: &Output) -> std::io::Result<()> {
     }
     Ok(())
 }
+
+/// Render the file level import graph. Edges point from the importing file to the imported module,
+/// with one edge per file pair regardless of how many names are imported. Nodes are numbered in
+/// path order so the output doesn't depend on the order files were scanned in.
+pub fn render_import_graph(graph: &ImportGraph, format: &GraphFormat) -> String {
+    let mut nodes = graph.nodes.clone();
+    nodes.sort_by(|a, b| a.path.cmp(&b.path));
+    let index: HashMap<usize, usize> = nodes.iter().enumerate().map(|(i, n)| (n.id, i)).collect();
+    let mut edges: Vec<(usize, usize)> = graph
+        .edges
+        .iter()
+        .map(|e| (index[&e.target], index[&e.source]))
+        .collect();
+    edges.sort();
+    edges.dedup();
+    let mut lines: Vec<String> = Vec::new();
+    match format {
+        GraphFormat::Mermaid => {
+            lines.push(String::from("graph LR"));
+            for (i, node) in nodes.iter().enumerate() {
+                lines.push(format!(
+                    "  n{}[\"{}\"]",
+                    i,
+                    node.path.replace('"', "#quot;")
+                ));
+            }
+            for (from, to) in &edges {
+                lines.push(format!("  n{} --> n{}", from, to));
+            }
+        }
+        GraphFormat::Dot => {
+            lines.push(String::from("digraph imports {"));
+            for (i, node) in nodes.iter().enumerate() {
+                lines.push(format!(
+                    "  n{} [label=\"{}\"];",
+                    i,
+                    node.path.replace('"', "\\\"")
+                ));
+            }
+            for (from, to) in &edges {
+                lines.push(format!("  n{} -> n{};", from, to));
+            }
+            lines.push(String::from("}"));
+        }
+    }
+    return lines.join("\n");
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::extract::extract_import_graph;
+    use crate::languages::{mock_file, mock_import, Import};
+    #[test]
+    fn test_render_import_graph() -> Result<(), String> {
+        let import = |name: &str| Import {
+            named: vec![name.to_string()],
+            is_default: false,
+            ..mock_import("src/App.js", "./Button")
+        };
+        let files = vec![
+            mock_file("src/App.js", vec![import("Button"), import("ButtonProps")]),
+            mock_file("src/Button.js", vec![]),
+        ];
+        let graph = extract_import_graph(&files, &vec![], &vec![]);
+        assert_eq!(graph.nodes.len(), 2);
+        assert_eq!(
+            render_import_graph(&graph, &GraphFormat::Dot),
+            "digraph imports {\n  n0 [label=\"src/App.js\"];\n  n1 [label=\"src/Button.js\"];\n  n0 -> n1;\n}"
+        );
+        assert_eq!(
+            render_import_graph(&graph, &GraphFormat::Mermaid),
+            "graph LR\n  n0[\"src/App.js\"]\n  n1[\"src/Button.js\"]\n  n0 --> n1"
+        );
+        Ok(())
+    }
+    #[test]
+    fn test_render_import_graph_is_order_independent() -> Result<(), String> {
+        let mut files = vec![
+            mock_file("src/App.js", vec![mock_import("src/App.js", "./Button")]),
+            mock_file(
+                "src/Button.js",
+                vec![mock_import("src/Button.js", "./theme")],
+            ),
+            mock_file("src/theme.js", vec![]),
+        ];
+        let graph = extract_import_graph(&files, &vec![], &vec![]);
+        files.reverse();
+        let reversed = extract_import_graph(&files, &vec![], &vec![]);
+        for format in [GraphFormat::Dot, GraphFormat::Mermaid] {
+            assert_eq!(
+                render_import_graph(&graph, &format),
+                render_import_graph(&reversed, &format)
+            );
+        }
+        Ok(())
+    }
+}
@@ -9,10 +9,12 @@ pub struct PackageJson {
     pub dependencies: Option<HashMap<String, String>>,
     pub dev_dependencies: Option<HashMap<String, String>>,
     pub peer_dependencies: Option<HashMap<String, String>>,
+    pub main: Option<String>,
+    pub module: Option<String>,
     pub file_path: Option<PathBuf>,
 }
 
-pub fn parse(package_jsons: Vec<PathBuf>) -> Vec<PackageJson> {
+pub fn parse(package_jsons: Vec<PathBuf>, root_prefix: PathBuf) -> Vec<PackageJson> {
     let mut result: Vec<PackageJson> = Vec::new();
     for p_json in package_jsons {
         let file_string = fs::read_to_string(&p_json).expect(&format!(
@@ -24,7 +26,8 @@ pub fn parse(package_jsons: Vec<PathBuf>) -> Vec<PackageJson> {
                 "JSON was not well-formatted in: {}",
                 &p_json.display().to_string()
             ));
-        parsed_p_json.file_path = Some(p_json);
+        // Remove root path
+        parsed_p_json.file_path = Some(p_json.strip_prefix(&root_prefix).unwrap().to_path_buf());
         result.push(parsed_p_json)
     }
     return result;
@@ -43,3 +46,11 @@ pub fn list_dependencies(package_json: PackageJson) -> Vec<String> {
     }
     return dependencies;
 }
+
+/// Entry files a package.json declares relative to its own directory, `main` before `module`.
+pub fn entry_points(package_json: &PackageJson) -> Vec<String> {
+    return [package_json.main.clone(), package_json.module.clone()]
+        .into_iter()
+        .flatten()
+        .collect();
+}
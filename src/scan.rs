@@ -6,12 +6,12 @@ use crate::package_json;
 use crate::package_json::PackageJson;
 use crate::ts_config;
 use crate::ts_config::TypeScriptConfig;
-use ignore::Walk;
+use ignore::WalkBuilder;
 use regex::Regex;
 use std::fs::metadata;
+use std::panic;
 use std::path::{Path, PathBuf};
 use std::sync::mpsc::channel;
-use std::time::Instant;
 use threadpool::ThreadPool;
 
 struct Files {
@@ -20,12 +20,30 @@ struct Files {
     ts_config: Vec<PathBuf>,
 }
 
-fn find_files(root_path: &Path, pattern: &Regex, ignore_pattern: &Regex) -> Files {
+/// Directories that are never worth scanning, in addition to hidden and git ignored ones.
+const DEFAULT_SKIP_DIRS: [&str; 1] = ["node_modules"];
+
+fn find_files(
+    root_path: &Path,
+    pattern: &Regex,
+    ignore_pattern: &Regex,
+    skip_dirs: &Vec<String>,
+) -> Files {
     let mut all_files: Vec<String> = Vec::new();
     let mut package_json: Vec<PathBuf> = Vec::new();
     let mut ts_config: Vec<PathBuf> = Vec::new();
+    let mut skip: Vec<String> = DEFAULT_SKIP_DIRS.iter().map(|d| d.to_string()).collect();
+    skip.extend(skip_dirs.iter().cloned());
+    let walker = WalkBuilder::new(root_path)
+        .filter_entry(move |entry| {
+            // Never skip the root itself, only directories found below it
+            let is_dir = entry.file_type().map_or(false, |t| t.is_dir());
+            let skipped = skip.iter().any(|d| entry.file_name() == d.as_str());
+            !(is_dir && entry.depth() > 0 && skipped)
+        })
+        .build();
     // Read path and validate
-    for entry in Walk::new(root_path) {
+    for entry in walker {
         if let Ok(entry) = entry {
             let file_path = entry.path();
             // If matches ignore, skip
@@ -54,16 +72,36 @@ fn find_files(root_path: &Path, pattern: &Regex, ignore_pattern: &Regex) -> File
         ts_config,
     };
 }
-/// Scan a given path and return all files parsed
+/// List the files a scan would parse, after ignore and skip filtering, without parsing them.
+pub fn list_files(
+    root_path: &Path,
+    pattern: &Regex,
+    ignore_pattern: &Regex,
+    skip_dirs: &Vec<String>,
+) -> Vec<String> {
+    let mut files = find_files(root_path, pattern, ignore_pattern, skip_dirs).all_files;
+    files.sort();
+    return files;
+}
+
+/// Scan a given path and return all files parsed. Files that fail to parse are returned separately,
+/// relative to the root, so the report can show the analysis was partial.
 pub fn scan(
     root_path: &Path,
     pattern: &Regex,
     ignore_pattern: &Regex,
-) -> (Vec<ParsedFile>, Vec<PackageJson>, Vec<TypeScriptConfig>) {
-    let now = Instant::now();
-    let f = find_files(root_path, pattern, ignore_pattern);
+    skip_dirs: &Vec<String>,
+) -> (
+    Vec<ParsedFile>,
+    Vec<String>,
+    Vec<PackageJson>,
+    Vec<TypeScriptConfig>,
+) {
+    let f = find_files(root_path, pattern, ignore_pattern, skip_dirs);
     let mut parsed_files: Vec<ParsedFile> = Vec::new();
-    let parsed_package_jsons: Vec<PackageJson> = package_json::parse(f.package_json);
+    let mut failed_files: Vec<String> = Vec::new();
+    let parsed_package_jsons: Vec<PackageJson> =
+        package_json::parse(f.package_json, PathBuf::from(root_path));
     let parsed_ts_configs: Vec<TypeScriptConfig> =
         ts_config::parse(f.ts_config, PathBuf::from(root_path));
     // We need to configure a fixed number of workers so we don't hit OS limits. On Mac the
@@ -79,24 +117,46 @@ pub fn scan(
             let prefix = PathBuf::from(root_path);
             pool.execute(move || {
                 let file_path = Path::new(&path);
-                let parsed = parse_file(&file_path, prefix);
-                if let Ok(p) = parsed {
-                    tx.send(p).unwrap();
-                }
+                // Every file must report back, even if parsing fails or panics, or we would wait
+                // on it forever. A single bad file shouldn't fail the entire scan.
+                let parsed = panic::catch_unwind(|| parse_file(&file_path, prefix));
+                let result = match parsed {
+                    Ok(Ok(p)) => Ok(p),
+                    _ => Err(path.clone()),
+                };
+                tx.send(result).unwrap();
             })
         })
         .collect();
     for _ in threads {
-        let p = rx.recv().unwrap();
-        parsed_files.push(p);
+        match rx.recv().unwrap() {
+            Ok(p) => parsed_files.push(p),
+            Err(path) => {
+                eprintln!("Unable to parse file: {}", path);
+                let file_path = Path::new(&path);
+                let relative = file_path.strip_prefix(root_path).unwrap_or(file_path);
+                failed_files.push(relative.display().to_string());
+            }
+        }
     }
-    let elapsed = now.elapsed();
-    println!("Scan done in: {:.2?}!", elapsed);
-    return (parsed_files, parsed_package_jsons, parsed_ts_configs);
+    // Workers finish in any order, sort so import graph ids are the same on every run
+    parsed_files.sort_by(|a, b| a.path.cmp(&b.path));
+    failed_files.sort();
+    return (
+        parsed_files,
+        failed_files,
+        parsed_package_jsons,
+        parsed_ts_configs,
+    );
 }
 
-pub fn scan_test_files(root_path: &Path, pattern: &Regex, ignore_pattern: &Regex) -> Vec<TestFile> {
-    let f = find_files(root_path, pattern, ignore_pattern);
+pub fn scan_test_files(
+    root_path: &Path,
+    pattern: &Regex,
+    ignore_pattern: &Regex,
+    skip_dirs: &Vec<String>,
+) -> Vec<TestFile> {
+    let f = find_files(root_path, pattern, ignore_pattern, skip_dirs);
     let mut test_files: Vec<TestFile> = Vec::new();
     for path in f.all_files {
         let parsed = parse_test_file(Path::new(&path));
@@ -106,3 +166,69 @@ pub fn scan_test_files(root_path: &Path, pattern: &Regex, ignore_pattern: &Regex
     }
     return test_files;
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::extract::extract;
+    use std::fs;
+    #[test]
+    fn test_skip_dirs() -> Result<(), String> {
+        let root = std::env::temp_dir().join("react-analyzer-skip-dirs");
+        let _ = fs::remove_dir_all(&root);
+        for dir in ["src", "out", "build", "node_modules"] {
+            fs::create_dir_all(root.join(dir)).unwrap();
+            fs::write(root.join(dir).join("index.js"), "").unwrap();
+        }
+        let pattern = Regex::new(r"^.*\.js$").unwrap();
+        let ignore_pattern = Regex::new(r".*.test.js").unwrap();
+        // Default skip dirs only, build output folders are left to .gitignore
+        let f = find_files(&root, &pattern, &ignore_pattern, &vec![]);
+        assert_eq!(f.all_files.len(), 3);
+        assert!(!f.all_files.iter().any(|p| p.contains("node_modules")));
+        // Custom skip dirs
+        let skip_dirs = vec![String::from("out"), String::from("build")];
+        let f = find_files(&root, &pattern, &ignore_pattern, &skip_dirs);
+        assert_eq!(f.all_files.len(), 1);
+        assert!(f.all_files[0].ends_with("index.js"));
+        assert!(f.all_files[0].contains("src"));
+        let _ = fs::remove_dir_all(&root);
+        Ok(())
+    }
+    #[test]
+    fn test_scan_survives_parse_panic() -> Result<(), String> {
+        let root = std::env::temp_dir().join("react-analyzer-parse-panic");
+        let _ = fs::remove_dir_all(&root);
+        fs::create_dir_all(&root).unwrap();
+        fs::write(root.join("App.js"), "import { a } from './a';").unwrap();
+        // No language handles .vue so parsing it panics
+        fs::write(root.join("App.vue"), "").unwrap();
+        let pattern = Regex::new(r"^.*\.(js|vue)$").unwrap();
+        let ignore_pattern = Regex::new(r".*.test.js").unwrap();
+        let (files, failed_files, _, _) = scan(&root, &pattern, &ignore_pattern, &vec![]);
+        assert_eq!(files.len(), 1);
+        assert_eq!(files[0].path, String::from("App.js"));
+        assert_eq!(failed_files, vec![String::from("App.vue")]);
+        let output = extract(&root, files, failed_files, vec![], vec![]);
+        assert_eq!(output.summary.failed_file_count, 1);
+        assert_eq!(output.failed_files, vec![String::from("App.vue")]);
+        let _ = fs::remove_dir_all(&root);
+        Ok(())
+    }
+    #[test]
+    fn test_scan_is_sorted() -> Result<(), String> {
+        let root = std::env::temp_dir().join("react-analyzer-scan-order");
+        let _ = fs::remove_dir_all(&root);
+        fs::create_dir_all(&root).unwrap();
+        for name in ["e", "d", "c", "b", "a"] {
+            fs::write(root.join(format!("{}.js", name)), "").unwrap();
+        }
+        let pattern = Regex::new(r"^.*\.js$").unwrap();
+        let ignore_pattern = Regex::new(r".*.test.js").unwrap();
+        let (files, _, _, _) = scan(&root, &pattern, &ignore_pattern, &vec![]);
+        let paths: Vec<&str> = files.iter().map(|f| f.path.as_str()).collect();
+        assert_eq!(paths, vec!["a.js", "b.js", "c.js", "d.js", "e.js"]);
+        let _ = fs::remove_dir_all(&root);
+        Ok(())
+    }
+}
@@ -0,0 +1,2 @@
+import defaultExport from "./module-name";
+import { export1, export2 } from "./module-name";